@@ -0,0 +1,81 @@
+package abcicli
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tendermint/abci/types"
+)
+
+// TestQueueRequestWithContextRespectsCancellation fills reqQueue so no
+// enqueue can succeed, then checks that a *Context call gives up with
+// ctx.Err() instead of blocking forever.
+func TestQueueRequestWithContextRespectsCancellation(t *testing.T) {
+	// An unbuffered reqs channel with nobody reading it means the send
+	// goroutine's first SendRequest call never returns, so it never comes
+	// back to drain reqQueue again.
+	conn := &fakeConn{
+		reqs:   make(chan *types.Request),
+		resps:  make(chan *types.Response),
+		closed: make(chan struct{}),
+	}
+	cli := NewSocketClientWithConfig("fake", true, newFakeTransport(conn), SocketClientConfig{
+		QueueSize: 1,
+	})
+	if err := cli.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer cli.Stop()
+
+	cli.EchoAsync("wedges the send goroutine inside SendRequest")
+	cli.EchoAsync("fills reqQueue (size 1), since nothing is draining it anymore")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := cli.EchoAsyncContext(ctx, "should not fit"); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+// TestWaitContextLeavesReqSentAloneOnCancellation covers the harder half of
+// cancellation: a request that already made it onto the wire and is sitting
+// in cli.reqSent awaiting a response nobody answers in time. waitContext
+// must give up with ctx.Err() without popping it off reqSent, since a late
+// answer still needs to land on the right ReqRes.
+func TestWaitContextLeavesReqSentAloneOnCancellation(t *testing.T) {
+	conn := newFakeConn()
+	cli := NewSocketClientWithTransport("fake", true, newFakeTransport(conn))
+	if err := cli.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer cli.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// Nothing answers on conn, so the Echo and its trailing Flush are both
+	// still sitting in cli.reqSent, unanswered, when ctx expires.
+	if _, err := cli.EchoSyncContext(ctx, "never answered"); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if n := cli.reqSent.Len(); n != 2 {
+		t.Fatalf("expected the cancelled Echo+Flush to remain in reqSent, got %d entries", n)
+	}
+
+	// Answering them now, in order, should drain reqSent normally instead
+	// of the late responses having nothing left to match against.
+	conn.resps <- &types.Response{Value: &types.Response_Echo{
+		Echo: &types.ResponseEcho{Message: "late"},
+	}}
+	conn.resps <- flushResponse()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) && cli.Stats().InFlight != 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := cli.Stats().InFlight; n != 0 {
+		t.Fatalf("expected the late responses to drain reqSent, got %d entries remaining", n)
+	}
+}
@@ -0,0 +1,99 @@
+package abcicli
+
+import (
+	"errors"
+
+	"github.com/tendermint/abci/types"
+)
+
+// fakeConn implements MessageConn over in-memory channels, so socketClient
+// can be driven end to end in tests without a real ABCI app on the other
+// end of a socket.
+type fakeConn struct {
+	reqs   chan *types.Request
+	resps  chan *types.Response
+	closed chan struct{}
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{
+		reqs:   make(chan *types.Request, 64),
+		resps:  make(chan *types.Response, 64),
+		closed: make(chan struct{}),
+	}
+}
+
+func (c *fakeConn) SendRequest(req *types.Request) error {
+	select {
+	case c.reqs <- req:
+		return nil
+	case <-c.closed:
+		return errors.New("fakeConn: closed")
+	}
+}
+
+func (c *fakeConn) RecvResponse(res *types.Response) error {
+	select {
+	case r, ok := <-c.resps:
+		if !ok {
+			return errors.New("fakeConn: closed")
+		}
+		*res = *r
+		return nil
+	case <-c.closed:
+		return errors.New("fakeConn: closed")
+	}
+}
+
+func (c *fakeConn) Flush() error { return nil }
+
+func (c *fakeConn) Close() error {
+	select {
+	case <-c.closed:
+	default:
+		close(c.closed)
+	}
+	return nil
+}
+
+// fakeTransport hands out a scripted sequence of fakeConns, one per Dial
+// call, so a test can simulate a dropped connection followed by a
+// successful reconnect.
+type fakeTransport struct {
+	conns chan *fakeConn
+}
+
+func newFakeTransport(conns ...*fakeConn) *fakeTransport {
+	ch := make(chan *fakeConn, len(conns))
+	for _, c := range conns {
+		ch <- c
+	}
+	return &fakeTransport{conns: ch}
+}
+
+func (t *fakeTransport) Dial(addr string) (MessageConn, error) {
+	select {
+	case c := <-t.conns:
+		return c, nil
+	default:
+		return nil, errors.New("fakeTransport: no more connections scripted")
+	}
+}
+
+func okDeliverTxResponse() *types.Response {
+	return &types.Response{Value: &types.Response_DeliverTx{
+		DeliverTx: &types.ResponseDeliverTx{Code: OK, Log: LOG},
+	}}
+}
+
+func exceptionResponse(msg string) *types.Response {
+	return &types.Response{Value: &types.Response_Exception{
+		Exception: &types.ResponseException{Error: msg},
+	}}
+}
+
+func flushResponse() *types.Response {
+	return &types.Response{Value: &types.Response_Flush{
+		Flush: &types.ResponseFlush{},
+	}}
+}
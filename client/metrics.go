@@ -0,0 +1,82 @@
+package abcicli
+
+import (
+	"time"
+
+	"github.com/tendermint/abci/types"
+)
+
+// Metrics instruments a socketClient's queue depth, in-flight requests,
+// flush cadence, and per-message latency. Implementations are expected to
+// be Prometheus gauges/counters/histograms, but the interface itself has no
+// Prometheus dependency.
+type Metrics interface {
+	// QueueSize reports the current number of requests sitting in reqQueue.
+	QueueSize(n int)
+	// InFlight reports the current number of requests sent but not yet
+	// answered (i.e. cli.reqSent.Len()).
+	InFlight(n int)
+	// RequestSent counts an outgoing request, labeled by message type.
+	RequestSent(messageType string)
+	// ResponseReceived counts an incoming response, labeled by message type.
+	ResponseReceived(messageType string)
+	// RequestLatency observes the time between willSendReq and
+	// didRecvResponse for a given message type.
+	RequestLatency(messageType string, d time.Duration)
+	// FlushSent counts a Flush request being written to the wire.
+	FlushSent()
+}
+
+// NopMetrics returns a Metrics that discards everything. It's the default
+// used when a SocketClientConfig doesn't set Metrics.
+func NopMetrics() Metrics {
+	return nopMetrics{}
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) QueueSize(int)                       {}
+func (nopMetrics) InFlight(int)                         {}
+func (nopMetrics) RequestSent(string)                   {}
+func (nopMetrics) ResponseReceived(string)              {}
+func (nopMetrics) RequestLatency(string, time.Duration) {}
+func (nopMetrics) FlushSent()                           {}
+
+// Stats is a point-in-time snapshot of queue depth and in-flight requests,
+// for callers that want a cheap poll instead of wiring up a Metrics
+// implementation.
+type Stats struct {
+	QueueSize int
+	InFlight  int
+}
+
+// requestTypeName returns a short, stable label for a request's message
+// type, suitable for use in metrics labels and log lines.
+func requestTypeName(req *types.Request) string {
+	switch req.Value.(type) {
+	case *types.Request_Echo:
+		return "echo"
+	case *types.Request_Flush:
+		return "flush"
+	case *types.Request_Info:
+		return "info"
+	case *types.Request_SetOption:
+		return "set_option"
+	case *types.Request_DeliverTx:
+		return "deliver_tx"
+	case *types.Request_CheckTx:
+		return "check_tx"
+	case *types.Request_Commit:
+		return "commit"
+	case *types.Request_Query:
+		return "query"
+	case *types.Request_InitChain:
+		return "init_chain"
+	case *types.Request_BeginBlock:
+		return "begin_block"
+	case *types.Request_EndBlock:
+		return "end_block"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,145 @@
+package abcicli
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tendermint/abci/types"
+)
+
+// fakeMetrics records every call made to it, guarded by a mutex since
+// socketClient drives it from its send/recv goroutines concurrently with
+// whatever goroutine is inspecting it.
+type fakeMetrics struct {
+	mtx sync.Mutex
+
+	queueSizes        []int
+	inFlights         []int
+	requestsSent      []string
+	responsesReceived []string
+	latencies         map[string]int
+	flushesSent       int
+}
+
+func newFakeMetrics() *fakeMetrics {
+	return &fakeMetrics{latencies: make(map[string]int)}
+}
+
+func (m *fakeMetrics) QueueSize(n int) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.queueSizes = append(m.queueSizes, n)
+}
+
+func (m *fakeMetrics) InFlight(n int) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.inFlights = append(m.inFlights, n)
+}
+
+func (m *fakeMetrics) RequestSent(messageType string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.requestsSent = append(m.requestsSent, messageType)
+}
+
+func (m *fakeMetrics) ResponseReceived(messageType string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.responsesReceived = append(m.responsesReceived, messageType)
+}
+
+func (m *fakeMetrics) RequestLatency(messageType string, d time.Duration) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.latencies[messageType]++
+}
+
+func (m *fakeMetrics) FlushSent() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.flushesSent++
+}
+
+func countOf(messageType string, of []string) int {
+	n := 0
+	for _, mt := range of {
+		if mt == messageType {
+			n++
+		}
+	}
+	return n
+}
+
+// TestMetricsInstrumentRequestResponseCycle drives a fakeMetrics through a
+// single EchoSync round trip and checks that every Metrics method gets
+// called with the expected values, and that Stats() matches the drained
+// queue/reqSent once the round trip completes.
+func TestMetricsInstrumentRequestResponseCycle(t *testing.T) {
+	conn := newFakeConn()
+	metrics := newFakeMetrics()
+	cli := NewSocketClientWithConfig("fake", true, newFakeTransport(conn), SocketClientConfig{
+		Metrics: metrics,
+	})
+	if err := cli.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer cli.Stop()
+
+	go func() {
+		for req := range conn.reqs {
+			switch req.Value.(type) {
+			case *types.Request_Echo:
+				conn.resps <- &types.Response{Value: &types.Response_Echo{
+					Echo: &types.ResponseEcho{Message: "hi"},
+				}}
+			case *types.Request_Flush:
+				conn.resps <- flushResponse()
+			}
+		}
+	}()
+
+	if _, err := cli.EchoSync("hi"); err != nil {
+		t.Fatalf("EchoSync: %v", err)
+	}
+
+	// Stats() takes cli.mtx, which didRecvResponse only releases after it
+	// has made all of its own metrics calls, so this also fences the
+	// fakeMetrics reads below against the recv goroutine.
+	stats := cli.Stats()
+	if stats.QueueSize != 0 || stats.InFlight != 0 {
+		t.Fatalf("expected a drained Stats snapshot after the round trip, got %+v", stats)
+	}
+
+	metrics.mtx.Lock()
+	defer metrics.mtx.Unlock()
+
+	if n := countOf("echo", metrics.requestsSent); n != 1 {
+		t.Fatalf("expected one echo RequestSent, got %v", metrics.requestsSent)
+	}
+	if n := countOf("flush", metrics.requestsSent); n != 1 {
+		t.Fatalf("expected one flush RequestSent, got %v", metrics.requestsSent)
+	}
+	if n := countOf("echo", metrics.responsesReceived); n != 1 {
+		t.Fatalf("expected one echo ResponseReceived, got %v", metrics.responsesReceived)
+	}
+	if n := countOf("flush", metrics.responsesReceived); n != 1 {
+		t.Fatalf("expected one flush ResponseReceived, got %v", metrics.responsesReceived)
+	}
+	if metrics.flushesSent != 1 {
+		t.Fatalf("expected FlushSent to be called once, got %d", metrics.flushesSent)
+	}
+	if metrics.latencies["echo"] != 1 || metrics.latencies["flush"] != 1 {
+		t.Fatalf("expected one RequestLatency observation per message type, got %v", metrics.latencies)
+	}
+	if len(metrics.queueSizes) == 0 {
+		t.Fatalf("expected at least one QueueSize observation")
+	}
+	if len(metrics.inFlights) == 0 {
+		t.Fatalf("expected at least one InFlight observation")
+	}
+	if last := metrics.inFlights[len(metrics.inFlights)-1]; last != 0 {
+		t.Fatalf("expected the last InFlight observation to be 0, got %d", last)
+	}
+}
@@ -0,0 +1,158 @@
+package abcicli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tendermint/abci/types"
+)
+
+// ErrConnectionLost is released to callers waiting on a ReqRes that was in
+// flight when the ABCI socket dropped out from under a reconnect-mode
+// client, instead of hanging on reqres.Wait() forever.
+var ErrConnectionLost = errors.New("abci: connection to application lost")
+
+const (
+	reconnectMinBackoff = 100 * time.Millisecond
+	reconnectMaxBackoff = 3 * time.Second
+)
+
+// isIdempotentRequest reports whether req can be safely replayed against a
+// freshly reconnected app without risking double-applying its effects.
+func isIdempotentRequest(req *types.Request) bool {
+	switch req.Value.(type) {
+	case *types.Request_Echo, *types.Request_Info, *types.Request_Query:
+		return true
+	default:
+		return false
+	}
+}
+
+// recoverConnection is StopForError's reconnect-mode counterpart: rather
+// than tearing the client down, it (1) fails every outstanding ReqRes in
+// reqSent with ErrConnectionLost (or, for idempotent requests when
+// RetryIdempotent is set, resubmits them), (2) does the same for whatever
+// is still sitting unsent in reqQueue, then (3) redials with exponential
+// backoff and (4) restarts the send/recv goroutines once back up.
+func (cli *socketClient) recoverConnection(err error) {
+	cli.mtx.Lock()
+	if cli.recovering {
+		// The send and recv goroutines share one conn: closing it to force
+		// one of them out unblocks the other, which arrives here right
+		// behind it. The first caller does the recovery; this one is just
+		// fallout from that and has nothing left to do.
+		cli.mtx.Unlock()
+		return
+	}
+	cli.recovering = true
+	cli.mtx.Unlock()
+
+	cli.Logger.Error(fmt.Sprintf("abci.socketClient lost connection, reconnecting: %v", err))
+
+	cli.mtx.Lock()
+	if cli.conn != nil {
+		cli.conn.Close()
+		cli.conn = nil
+	}
+	inFlight := make([]*ReqRes, 0, cli.reqSent.Len())
+	for e := cli.reqSent.Front(); e != nil; e = e.Next() {
+		inFlight = append(inFlight, e.Value.(*ReqRes))
+	}
+	cli.reqSent.Init()
+	cli.sentAt = make(map[*ReqRes]time.Time)
+	cli.mtx.Unlock()
+	cli.metrics.InFlight(0)
+
+	cli.drainQueueForReconnect()
+	cli.resolveInFlight(inFlight)
+
+	go cli.redialLoop()
+}
+
+// failReqRes records err as the client's (transient) error and releases
+// reqres's waiter. The error is cleared once redialLoop reconnects, so it
+// only applies to requests that were live at the moment the connection
+// dropped.
+func (cli *socketClient) failReqRes(reqres *ReqRes, err error) {
+	cli.mtx.Lock()
+	if cli.err == nil {
+		cli.err = err
+	}
+	cli.mtx.Unlock()
+	reqres.Done()
+}
+
+// resolveInFlight fails every request that was already sent and awaiting a
+// response when the connection dropped, except idempotent ones when
+// RetryIdempotent is enabled, which are resubmitted via requeueForReplay to
+// be replayed against the new connection.
+func (cli *socketClient) resolveInFlight(inFlight []*ReqRes) {
+	for _, reqres := range inFlight {
+		if cli.retryIdempotent && isIdempotentRequest(reqres.Request) {
+			cli.requeueForReplay(reqres)
+			continue
+		}
+		cli.failReqRes(reqres, ErrConnectionLost)
+	}
+}
+
+// drainQueueForReconnect resolves whatever was still buffered in reqQueue,
+// unsent, when the connection dropped: non-idempotent requests are failed
+// with ErrConnectionLost, idempotent ones are put back via requeueForReplay
+// (when RetryIdempotent is set) to be sent once the new connection is up.
+func (cli *socketClient) drainQueueForReconnect() {
+	pending := len(cli.reqQueue)
+	for i := 0; i < pending; i++ {
+		reqres := <-cli.reqQueue
+		if cli.retryIdempotent && isIdempotentRequest(reqres.Request) {
+			cli.requeueForReplay(reqres)
+			continue
+		}
+		cli.failReqRes(reqres, ErrConnectionLost)
+	}
+}
+
+// requeueForReplay puts reqres back on reqQueue for replay against the
+// reconnected app. It never blocks: reqQueue can already be full of
+// requests concurrent callers are queuing, and recoverConnection runs
+// synchronously on the send/recv goroutine, so a blocking send here would
+// deadlock the client instead of recovering it. If there's no room, reqres
+// is failed with ErrConnectionLost like any other in-flight request.
+func (cli *socketClient) requeueForReplay(reqres *ReqRes) {
+	select {
+	case cli.reqQueue <- reqres:
+	default:
+		cli.failReqRes(reqres, ErrConnectionLost)
+	}
+}
+
+// redialLoop redials cli.addr with exponential backoff until it succeeds or
+// the client is stopped, then clears the transient connection-lost error
+// and resumes the send/recv goroutines against the new connection.
+func (cli *socketClient) redialLoop() {
+	backoff := reconnectMinBackoff
+	for {
+		if !cli.IsRunning() {
+			return
+		}
+
+		conn, err := cli.transport.Dial(cli.addr)
+		if err != nil {
+			cli.Logger.Error(fmt.Sprintf("abci.socketClient failed to reconnect to %v, retrying in %v: %v", cli.addr, backoff, err))
+			time.Sleep(backoff)
+			if backoff *= 2; backoff > reconnectMaxBackoff {
+				backoff = reconnectMaxBackoff
+			}
+			continue
+		}
+
+		cli.mtx.Lock()
+		cli.err = nil
+		cli.recovering = false
+		cli.mtx.Unlock()
+
+		cli.setConnAndServe(conn)
+		return
+	}
+}
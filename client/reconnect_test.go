@@ -0,0 +1,70 @@
+package abcicli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tendermint/abci/types"
+)
+
+// TestReconnectFailsInFlightRequests drops the connection while a
+// DeliverTx is in flight and checks that, in reconnect mode, Wait()
+// returns instead of hanging, cli.Error() surfaces ErrConnectionLost, and
+// the client recovers enough to serve a request on the reconnected conn.
+func TestReconnectFailsInFlightRequests(t *testing.T) {
+	conn1 := newFakeConn()
+	conn2 := newFakeConn()
+
+	// conn2 is only handed to the transport once cli.Error() has been
+	// checked below, so redialLoop can't reconnect (and clear the error)
+	// before then.
+	transport := newFakeTransport(conn1)
+	cli := NewSocketClientWithConfig("fake", true, transport, SocketClientConfig{
+		Reconnect: true,
+	})
+	if err := cli.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer cli.Stop()
+
+	// Let the DeliverTx request reach conn1, then drop it without ever
+	// answering: the app vanished mid-request.
+	go func() {
+		<-conn1.reqs
+		conn1.Close()
+	}()
+
+	reqres := cli.DeliverTxAsync([]byte("tx"))
+	reqres.Wait()
+
+	if err := errors.Cause(cli.Error()); err != ErrConnectionLost {
+		t.Fatalf("expected ErrConnectionLost after a dropped in-flight request, got %v", err)
+	}
+
+	transport.conns <- conn2
+
+	// Serve whatever the client sends on the reconnected conn so Stats()
+	// below observes a client that's back to normal, not one still wedged.
+	go func() {
+		for req := range conn2.reqs {
+			switch req.Value.(type) {
+			case *types.Request_Echo:
+				conn2.resps <- &types.Response{Value: &types.Response_Echo{
+					Echo: &types.ResponseEcho{Message: "hi"},
+				}}
+			case *types.Request_Flush:
+				conn2.resps <- flushResponse()
+			}
+		}
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := cli.EchoSync("hi"); err == nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("client never recovered enough to serve a request on the reconnected conn")
+}
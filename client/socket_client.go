@@ -1,10 +1,9 @@
 package abcicli
 
 import (
-	"bufio"
 	"container/list"
+	"context"
 	"fmt"
-	"net"
 	"reflect"
 	"sync"
 	"time"
@@ -19,55 +18,142 @@ const (
 	LOG = ""
 )
 
-const reqQueueSize = 256 // TODO make configurable
+const reqQueueSize = 256 // default; override via SocketClientConfig
 // const maxResponseSize = 1048576 // 1MB TODO make configurable
-const flushThrottleMS = 20 // Don't wait longer than...
+const flushThrottleMS = 20 // default; override via SocketClientConfig
 
 var _ Client = (*socketClient)(nil)
 
+// SocketClientConfig tunes the queueing/flush behavior that used to be
+// hardcoded as reqQueueSize and flushThrottleMS, and wires up a Metrics
+// implementation.
+type SocketClientConfig struct {
+	// QueueSize bounds how many requests can be buffered in reqQueue before
+	// queueRequest blocks (or, for the *Context variants, before ctx.Done()
+	// is honored). Defaults to reqQueueSize.
+	QueueSize int
+	// FlushThrottleMS is the minimum spacing, in milliseconds, between the
+	// automatic flushes triggered by a queued-but-unflushed request.
+	// Defaults to flushThrottleMS.
+	FlushThrottleMS int
+	// Metrics receives queue depth, in-flight, and latency instrumentation.
+	// Defaults to NopMetrics().
+	Metrics Metrics
+	// Reconnect, when true, makes the client survive a dropped connection:
+	// requests in flight when the socket fails are released with
+	// ErrConnectionLost instead of hanging forever, and the client redials
+	// with exponential backoff and resumes serving requests, rather than
+	// tearing itself down for good like StopForError normally does.
+	Reconnect bool
+	// RetryIdempotent, when true (and Reconnect is set), transparently
+	// retries idempotent queries (Echo, Info, Query) against the
+	// reconnected app instead of failing them with ErrConnectionLost.
+	RetryIdempotent bool
+}
+
+// DefaultSocketClientConfig returns the SocketClientConfig used when
+// NewSocketClient / NewSocketClientWithTransport are called directly.
+func DefaultSocketClientConfig() SocketClientConfig {
+	return SocketClientConfig{
+		QueueSize:       reqQueueSize,
+		FlushThrottleMS: flushThrottleMS,
+		Metrics:         NopMetrics(),
+	}
+}
+
 // This is goroutine-safe, but users should beware that
 // the application in general is not meant to be interfaced
 // with concurrent callers.
 type socketClient struct {
 	cmn.BaseService
 
-	reqQueue    chan *ReqRes
-	flushTimer  *cmn.ThrottleTimer
-	mustConnect bool
-
-	mtx     sync.Mutex
-	addr    string
-	conn    net.Conn
-	err     error
-	reqSent *list.List
-	resCb   func(*types.Request, *types.Response) // listens to all callbacks
+	reqQueue        chan *ReqRes
+	flushTimer      *cmn.ThrottleTimer
+	mustConnect     bool
+	transport       Transport
+	metrics         Metrics
+	reconnect       bool
+	retryIdempotent bool
+
+	mtx        sync.Mutex
+	addr       string
+	conn       MessageConn
+	err        error
+	reqSent    *list.List
+	sentAt     map[*ReqRes]time.Time                 // when each in-flight reqres was written, for latency metrics
+	recovering bool                                  // true while a reconnect-mode recoverConnection/redialLoop is in progress
+	resCb      func(*types.Request, *types.Response) // listens to all callbacks
+
+}
+
+// NewSocketClient returns a socketClient that dials addr using the original
+// raw, length-prefixed protobuf framing. Use NewSocketClientWithTransport to
+// plug in a different Transport (TLS, Unix socket, ...), or
+// NewSocketClientWithConfig to also tune queueing and metrics.
+func NewSocketClient(addr string, mustConnect bool) *socketClient {
+	return NewSocketClientWithConfig(addr, mustConnect, NewRawTransport(), DefaultSocketClientConfig())
+}
 
+// NewSocketClientWithTransport is like NewSocketClient, but dials addr
+// through the given Transport instead of the default raw framing.
+func NewSocketClientWithTransport(addr string, mustConnect bool, transport Transport) *socketClient {
+	return NewSocketClientWithConfig(addr, mustConnect, transport, DefaultSocketClientConfig())
 }
 
-func NewSocketClient(addr string, mustConnect bool) *socketClient {
+// NewSocketClientWithConfig is the fully-general constructor: it dials addr
+// through transport, and applies config's queue size, flush throttle, and
+// Metrics instrumentation.
+func NewSocketClientWithConfig(addr string, mustConnect bool, transport Transport, config SocketClientConfig) *socketClient {
+	if config.QueueSize == 0 {
+		config.QueueSize = reqQueueSize
+	}
+	if config.FlushThrottleMS == 0 {
+		config.FlushThrottleMS = flushThrottleMS
+	}
+	if config.Metrics == nil {
+		config.Metrics = NopMetrics()
+	}
+
 	cli := &socketClient{
-		reqQueue:    make(chan *ReqRes, reqQueueSize),
-		flushTimer:  cmn.NewThrottleTimer("socketClient", flushThrottleMS),
-		mustConnect: mustConnect,
+		reqQueue:        make(chan *ReqRes, config.QueueSize),
+		flushTimer:      cmn.NewThrottleTimer("socketClient", config.FlushThrottleMS),
+		mustConnect:     mustConnect,
+		transport:       transport,
+		metrics:         config.Metrics,
+		reconnect:       config.Reconnect,
+		retryIdempotent: config.RetryIdempotent,
 
 		addr:    addr,
 		reqSent: list.New(),
+		sentAt:  make(map[*ReqRes]time.Time),
 		resCb:   nil,
 	}
 	cli.BaseService = *cmn.NewBaseService(nil, "socketClient", cli)
 	return cli
 }
 
+// Stats returns a point-in-time snapshot of queue depth and in-flight
+// requests, for callers that want a cheap poll instead of wiring up a
+// Metrics implementation.
+func (cli *socketClient) Stats() Stats {
+	cli.mtx.Lock()
+	defer cli.mtx.Unlock()
+	return Stats{
+		QueueSize: len(cli.reqQueue),
+		InFlight:  cli.reqSent.Len(),
+	}
+}
+
 func (cli *socketClient) OnStart() error {
 	if err := cli.BaseService.OnStart(); err != nil {
 		return err
 	}
 
 	var err error
-	var conn net.Conn
+	var conn MessageConn
 RETRY_LOOP:
 	for {
-		conn, err = cmn.Connect(cli.addr)
+		conn, err = cli.transport.Dial(cli.addr)
 		if err != nil {
 			if cli.mustConnect {
 				return err
@@ -76,15 +162,23 @@ RETRY_LOOP:
 			time.Sleep(time.Second * 3)
 			continue RETRY_LOOP
 		}
-		cli.conn = conn
-
-		go cli.sendRequestsRoutine(conn)
-		go cli.recvResponseRoutine(conn)
-
+		cli.setConnAndServe(conn)
 		return nil
 	}
 }
 
+// setConnAndServe records conn as the active connection and starts the
+// send/recv goroutines against it. Used both by OnStart and, in reconnect
+// mode, by redialLoop after a dropped connection is replaced.
+func (cli *socketClient) setConnAndServe(conn MessageConn) {
+	cli.mtx.Lock()
+	cli.conn = conn
+	cli.mtx.Unlock()
+
+	go cli.sendRequestsRoutine(conn)
+	go cli.recvResponseRoutine(conn)
+}
+
 func (cli *socketClient) OnStop() {
 	cli.BaseService.OnStop()
 
@@ -97,22 +191,54 @@ func (cli *socketClient) OnStop() {
 	cli.flushQueue()
 }
 
-// Stop the client and set the error
+// Stop the client and set the error. In reconnect mode, a read/write
+// failure instead triggers recoverConnection, which fails in-flight
+// requests and redials rather than tearing the whole client down.
 func (cli *socketClient) StopForError(err error) {
 	if !cli.IsRunning() {
 		return
 	}
 
+	if cli.reconnect {
+		cli.recoverConnection(err)
+		return
+	}
+
 	cli.mtx.Lock()
 	if cli.err == nil {
 		cli.err = err
 	}
 	cli.mtx.Unlock()
 
+	// The connection is going down for good: nothing is left to answer
+	// whatever is still sitting in reqSent, so release those waiters now
+	// instead of leaving their reqres.Wait() hanging forever.
+	cli.failPendingRequests()
+
 	cli.Logger.Error(fmt.Sprintf("Stopping abci.socketClient for error: %v", err.Error()))
 	cli.Stop()
 }
 
+// failPendingRequests drains reqSent and releases every ReqRes still
+// waiting on it, with whatever error cli.err already holds (the caller is
+// expected to have set it). Their Response stays nil, so Wait()'ers can
+// tell a failed request apart from a completed one.
+func (cli *socketClient) failPendingRequests() {
+	cli.mtx.Lock()
+	pending := make([]*ReqRes, 0, cli.reqSent.Len())
+	for e := cli.reqSent.Front(); e != nil; e = e.Next() {
+		pending = append(pending, e.Value.(*ReqRes))
+	}
+	cli.reqSent.Init()
+	cli.sentAt = make(map[*ReqRes]time.Time)
+	cli.mtx.Unlock()
+	cli.metrics.InFlight(0)
+
+	for _, reqres := range pending {
+		reqres.Done()
+	}
+}
+
 func (cli *socketClient) Error() error {
 	cli.mtx.Lock()
 	defer cli.mtx.Unlock()
@@ -129,9 +255,8 @@ func (cli *socketClient) SetResponseCallback(resCb Callback) {
 
 //----------------------------------------
 
-func (cli *socketClient) sendRequestsRoutine(conn net.Conn) {
+func (cli *socketClient) sendRequestsRoutine(conn MessageConn) {
 
-	w := bufio.NewWriter(conn)
 	for {
 		select {
 		case <-cli.flushTimer.Ch:
@@ -144,36 +269,35 @@ func (cli *socketClient) sendRequestsRoutine(conn net.Conn) {
 			return
 		case reqres := <-cli.reqQueue:
 			cli.willSendReq(reqres)
-			err := types.WriteMessage(reqres.Request, w)
+			err := conn.SendRequest(reqres.Request)
 			if err != nil {
 				cli.StopForError(fmt.Errorf("Error writing msg: %v", err))
 				return
 			}
 			// cli.Logger.Debug("Sent request", "requestType", reflect.TypeOf(reqres.Request), "request", reqres.Request)
 			if _, ok := reqres.Request.Value.(*types.Request_Flush); ok {
-				err = w.Flush()
+				err = conn.Flush()
 				if err != nil {
 					cli.StopForError(fmt.Errorf("Error flushing writer: %v", err))
 					return
 				}
+				cli.metrics.FlushSent()
 			}
 		}
 	}
 }
 
-func (cli *socketClient) recvResponseRoutine(conn net.Conn) {
+func (cli *socketClient) recvResponseRoutine(conn MessageConn) {
 
-	r := bufio.NewReader(conn) // Buffer reads
 	for {
 		var res = &types.Response{}
-		err := types.ReadMessage(r, res)
+		err := conn.RecvResponse(res)
 		if err != nil {
 			cli.StopForError(err)
 			return
 		}
 		switch r := res.Value.(type) {
 		case *types.Response_Exception:
-			// XXX After setting cli.err, release waiters (e.g. reqres.Done())
 			cli.StopForError(errors.New(r.Exception.Error))
 			return
 		default:
@@ -191,6 +315,9 @@ func (cli *socketClient) willSendReq(reqres *ReqRes) {
 	cli.mtx.Lock()
 	defer cli.mtx.Unlock()
 	cli.reqSent.PushBack(reqres)
+	cli.sentAt[reqres] = time.Now()
+	cli.metrics.RequestSent(requestTypeName(reqres.Request))
+	cli.metrics.InFlight(cli.reqSent.Len())
 }
 
 func (cli *socketClient) didRecvResponse(res *types.Response) error {
@@ -212,6 +339,13 @@ func (cli *socketClient) didRecvResponse(res *types.Response) error {
 	reqres.Done()            // Release waiters
 	cli.reqSent.Remove(next) // Pop first item from linked list
 
+	if sentAt, ok := cli.sentAt[reqres]; ok {
+		cli.metrics.RequestLatency(requestTypeName(reqres.Request), time.Since(sentAt))
+		delete(cli.sentAt, reqres)
+	}
+	cli.metrics.ResponseReceived(requestTypeName(reqres.Request))
+	cli.metrics.InFlight(cli.reqSent.Len())
+
 	// Notify reqRes listener if set
 	if cb := reqres.GetCallback(); cb != nil {
 		cb(res)
@@ -271,6 +405,183 @@ func (cli *socketClient) EndBlockAsync(height uint64) *ReqRes {
 	return cli.queueRequest(types.ToRequestEndBlock(height))
 }
 
+//----------------------------------------
+// Context-aware variants bound how long a caller will block on a slow or
+// stuck ABCI app: Context calls fail fast with ctx.Err() instead of hanging
+// on a full reqQueue or an unanswered request.
+
+func (cli *socketClient) EchoAsyncContext(ctx context.Context, msg string) (*ReqRes, error) {
+	return cli.queueRequestWithContext(ctx, types.ToRequestEcho(msg))
+}
+
+func (cli *socketClient) FlushAsyncContext(ctx context.Context) (*ReqRes, error) {
+	return cli.queueRequestWithContext(ctx, types.ToRequestFlush())
+}
+
+func (cli *socketClient) InfoAsyncContext(ctx context.Context, req types.RequestInfo) (*ReqRes, error) {
+	return cli.queueRequestWithContext(ctx, types.ToRequestInfo(req))
+}
+
+func (cli *socketClient) SetOptionAsyncContext(ctx context.Context, key string, value string) (*ReqRes, error) {
+	return cli.queueRequestWithContext(ctx, types.ToRequestSetOption(key, value))
+}
+
+func (cli *socketClient) DeliverTxAsyncContext(ctx context.Context, tx []byte) (*ReqRes, error) {
+	return cli.queueRequestWithContext(ctx, types.ToRequestDeliverTx(tx))
+}
+
+func (cli *socketClient) CheckTxAsyncContext(ctx context.Context, tx []byte) (*ReqRes, error) {
+	return cli.queueRequestWithContext(ctx, types.ToRequestCheckTx(tx))
+}
+
+func (cli *socketClient) QueryAsyncContext(ctx context.Context, reqQuery types.RequestQuery) (*ReqRes, error) {
+	return cli.queueRequestWithContext(ctx, types.ToRequestQuery(reqQuery))
+}
+
+func (cli *socketClient) CommitAsyncContext(ctx context.Context) (*ReqRes, error) {
+	return cli.queueRequestWithContext(ctx, types.ToRequestCommit())
+}
+
+func (cli *socketClient) InitChainAsyncContext(ctx context.Context, params types.RequestInitChain) (*ReqRes, error) {
+	return cli.queueRequestWithContext(ctx, types.ToRequestInitChain(params))
+}
+
+func (cli *socketClient) BeginBlockAsyncContext(ctx context.Context, params types.RequestBeginBlock) (*ReqRes, error) {
+	return cli.queueRequestWithContext(ctx, types.ToRequestBeginBlock(params))
+}
+
+func (cli *socketClient) EndBlockAsyncContext(ctx context.Context, height uint64) (*ReqRes, error) {
+	return cli.queueRequestWithContext(ctx, types.ToRequestEndBlock(height))
+}
+
+//----------------------------------------
+
+func (cli *socketClient) FlushSyncContext(ctx context.Context) error {
+	reqRes, err := cli.queueRequestWithContext(ctx, types.ToRequestFlush())
+	if err != nil {
+		return err
+	}
+	if err := cli.Error(); err != nil {
+		return err
+	}
+	// NOTE: if we don't flush the queue, its possible to get stuck here
+	if err := cli.waitContext(ctx, reqRes); err != nil {
+		return err
+	}
+	return cli.Error()
+}
+
+func (cli *socketClient) EchoSyncContext(ctx context.Context, msg string) (*types.ResponseEcho, error) {
+	reqres, err := cli.queueRequestWithContext(ctx, types.ToRequestEcho(msg))
+	if err != nil {
+		return nil, err
+	}
+	if err := cli.FlushSyncContext(ctx); err != nil {
+		return nil, err
+	}
+	return reqres.Response.GetEcho(), cli.Error()
+}
+
+func (cli *socketClient) InfoSyncContext(ctx context.Context, req types.RequestInfo) (*types.ResponseInfo, error) {
+	reqres, err := cli.queueRequestWithContext(ctx, types.ToRequestInfo(req))
+	if err != nil {
+		return nil, err
+	}
+	if err := cli.FlushSyncContext(ctx); err != nil {
+		return nil, err
+	}
+	return reqres.Response.GetInfo(), cli.Error()
+}
+
+func (cli *socketClient) SetOptionSyncContext(ctx context.Context, key string, value string) (log string, err error) {
+	reqres, err := cli.queueRequestWithContext(ctx, types.ToRequestSetOption(key, value))
+	if err != nil {
+		return "", err
+	}
+	if err := cli.FlushSyncContext(ctx); err != nil {
+		return "", err
+	}
+	if err := cli.Error(); err != nil {
+		return "", err
+	}
+	return reqres.Response.GetSetOption().Log, nil
+}
+
+func (cli *socketClient) DeliverTxSyncContext(ctx context.Context, tx []byte) (*types.ResponseDeliverTx, error) {
+	reqres, err := cli.queueRequestWithContext(ctx, types.ToRequestDeliverTx(tx))
+	if err != nil {
+		return nil, err
+	}
+	if err := cli.FlushSyncContext(ctx); err != nil {
+		return nil, err
+	}
+	return reqres.Response.GetDeliverTx(), cli.Error()
+}
+
+func (cli *socketClient) CheckTxSyncContext(ctx context.Context, tx []byte) (*types.ResponseCheckTx, error) {
+	reqres, err := cli.queueRequestWithContext(ctx, types.ToRequestCheckTx(tx))
+	if err != nil {
+		return nil, err
+	}
+	if err := cli.FlushSyncContext(ctx); err != nil {
+		return nil, err
+	}
+	return reqres.Response.GetCheckTx(), cli.Error()
+}
+
+func (cli *socketClient) QuerySyncContext(ctx context.Context, req types.RequestQuery) (*types.ResponseQuery, error) {
+	reqres, err := cli.queueRequestWithContext(ctx, types.ToRequestQuery(req))
+	if err != nil {
+		return nil, err
+	}
+	if err := cli.FlushSyncContext(ctx); err != nil {
+		return nil, err
+	}
+	return reqres.Response.GetQuery(), cli.Error()
+}
+
+func (cli *socketClient) CommitSyncContext(ctx context.Context) (*types.ResponseCommit, error) {
+	reqres, err := cli.queueRequestWithContext(ctx, types.ToRequestCommit())
+	if err != nil {
+		return nil, err
+	}
+	if err := cli.FlushSyncContext(ctx); err != nil {
+		return nil, err
+	}
+	return reqres.Response.GetCommit(), cli.Error()
+}
+
+func (cli *socketClient) InitChainSyncContext(ctx context.Context, params types.RequestInitChain) error {
+	if _, err := cli.queueRequestWithContext(ctx, types.ToRequestInitChain(params)); err != nil {
+		return err
+	}
+	if err := cli.FlushSyncContext(ctx); err != nil {
+		return err
+	}
+	return cli.Error()
+}
+
+func (cli *socketClient) BeginBlockSyncContext(ctx context.Context, params types.RequestBeginBlock) error {
+	if _, err := cli.queueRequestWithContext(ctx, types.ToRequestBeginBlock(params)); err != nil {
+		return err
+	}
+	if err := cli.FlushSyncContext(ctx); err != nil {
+		return err
+	}
+	return cli.Error()
+}
+
+func (cli *socketClient) EndBlockSyncContext(ctx context.Context, height uint64) (*types.ResponseEndBlock, error) {
+	reqres, err := cli.queueRequestWithContext(ctx, types.ToRequestEndBlock(height))
+	if err != nil {
+		return nil, err
+	}
+	if err := cli.FlushSyncContext(ctx); err != nil {
+		return nil, err
+	}
+	return reqres.Response.GetEndBlock(), cli.Error()
+}
+
 //----------------------------------------
 
 func (cli *socketClient) FlushSync() error {
@@ -345,6 +656,49 @@ func (cli *socketClient) EndBlockSync(height uint64) (*types.ResponseEndBlock, e
 	return reqres.Response.GetEndBlock(), cli.Error()
 }
 
+//----------------------------------------
+// Batch DeliverTx lets callers pipeline a whole block's worth of txs: every
+// request is written back-to-back with a single trailing Flush, instead of
+// the one-write-one-flush-one-wait round trip DeliverTxSync does per tx.
+
+// DeliverTxBatchAsync queues txs one after another through queueRequest,
+// the same as the rest of the *Async methods: it queues no Flush of its own
+// (only the throttled auto-flush that queueRequest arms can fire), so it's
+// up to the caller (e.g. DeliverTxBatchSync) to queue a single trailing
+// Flush once the whole batch is queued.
+func (cli *socketClient) DeliverTxBatchAsync(txs [][]byte) []*ReqRes {
+	reqresList := make([]*ReqRes, len(txs))
+	for i, tx := range txs {
+		reqresList[i] = cli.queueRequest(types.ToRequestDeliverTx(tx))
+	}
+	return reqresList
+}
+
+// DeliverTxBatchSync pipelines txs via DeliverTxBatchAsync behind a single
+// trailing Flush, amortizing the per-tx framing/syscall overhead that
+// DeliverTxSync pays on every call. Responses are returned in the same
+// order as txs. If the app raises an Exception partway through, the
+// reqres for the tx that never got answered comes back from Wait() with a
+// nil Response (failed out from under it rather than completed), so the
+// responses collected up to that point are returned alongside an error
+// naming its index, instead of trusting the trailing Flush's own result.
+func (cli *socketClient) DeliverTxBatchSync(txs [][]byte) ([]*types.ResponseDeliverTx, error) {
+	reqresList := cli.DeliverTxBatchAsync(txs)
+	flushReqRes := cli.queueRequest(types.ToRequestFlush())
+
+	responses := make([]*types.ResponseDeliverTx, len(reqresList))
+	for i, reqres := range reqresList {
+		reqres.Wait()
+		if reqres.Response == nil {
+			return responses[:i], fmt.Errorf("DeliverTx batch failed at tx %d: %v", i, cli.Error())
+		}
+		responses[i] = reqres.Response.GetDeliverTx()
+	}
+
+	flushReqRes.Wait()
+	return responses, cli.Error()
+}
+
 //----------------------------------------
 
 func (cli *socketClient) queueRequest(req *types.Request) *ReqRes {
@@ -352,6 +706,7 @@ func (cli *socketClient) queueRequest(req *types.Request) *ReqRes {
 
 	// TODO: set cli.err if reqQueue times out
 	cli.reqQueue <- reqres
+	cli.metrics.QueueSize(len(cli.reqQueue))
 
 	// Maybe auto-flush, or unset auto-flush
 	switch req.Value.(type) {
@@ -364,6 +719,56 @@ func (cli *socketClient) queueRequest(req *types.Request) *ReqRes {
 	return reqres
 }
 
+// queueRequestWithContext is like queueRequest, except it gives up and
+// returns ctx.Err() if ctx is cancelled before the request can be placed on
+// reqQueue (e.g. the queue is full and the app is stuck), instead of
+// blocking forever.
+func (cli *socketClient) queueRequestWithContext(ctx context.Context, req *types.Request) (*ReqRes, error) {
+	reqres := NewReqRes(req)
+
+	select {
+	case cli.reqQueue <- reqres:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-cli.BaseService.Quit:
+		return nil, errors.New("client has stopped")
+	}
+	cli.metrics.QueueSize(len(cli.reqQueue))
+
+	// Maybe auto-flush, or unset auto-flush
+	switch req.Value.(type) {
+	case *types.Request_Flush:
+		cli.flushTimer.Unset()
+	default:
+		cli.flushTimer.Set()
+	}
+
+	return reqres, nil
+}
+
+// waitContext blocks until reqres' response arrives or ctx is cancelled,
+// whichever comes first. If ctx is cancelled first, it returns ctx.Err()
+// without touching cli.reqSent: this is intentional, not an oversight.
+// reqres was already handed to the server, so its entry must stay exactly
+// where it is and get popped off in its proper turn by didRecvResponse when
+// the response eventually arrives; removing it here would misalign the FIFO
+// match-up for every request sent after it, even though nobody is waiting
+// on this one anymore.
+func (cli *socketClient) waitContext(ctx context.Context, reqres *ReqRes) error {
+	done := make(chan struct{})
+	go func() {
+		reqres.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (cli *socketClient) flushQueue() {
 LOOP:
 	for {
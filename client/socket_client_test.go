@@ -0,0 +1,53 @@
+package abcicli
+
+import (
+	"testing"
+
+	"github.com/tendermint/abci/types"
+)
+
+// TestDeliverTxBatchSyncPartialFailure exercises an app that raises an
+// Exception partway through a DeliverTxBatchSync batch: the responses
+// collected before the failure must come back alongside an error naming
+// the failing tx's index, not be discarded in favor of the trailing
+// Flush's own (also-failed) result.
+func TestDeliverTxBatchSyncPartialFailure(t *testing.T) {
+	conn := newFakeConn()
+	cli := NewSocketClientWithTransport("fake", true, newFakeTransport(conn))
+	if err := cli.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer cli.Stop()
+
+	const failAt = 2 // 0-indexed tx that gets the Exception instead of a response
+	go func() {
+		i := 0
+		for req := range conn.reqs {
+			switch req.Value.(type) {
+			case *types.Request_DeliverTx:
+				if i == failAt {
+					conn.resps <- exceptionResponse("boom")
+					return
+				}
+				i++
+				conn.resps <- okDeliverTxResponse()
+			case *types.Request_Flush:
+				conn.resps <- flushResponse()
+			}
+		}
+	}()
+
+	txs := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")}
+	responses, err := cli.DeliverTxBatchSync(txs)
+	if err == nil {
+		t.Fatalf("expected an error from the mid-batch Exception")
+	}
+	if len(responses) != failAt {
+		t.Fatalf("expected %d completed responses before the failure, got %d", failAt, len(responses))
+	}
+	for i, res := range responses {
+		if res == nil {
+			t.Fatalf("response %d should have been collected before the failure", i)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package abcicli
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tendermint/abci/types"
+)
+
+// serveOneEcho accepts a single connection on ln, reads one Request off it
+// with the same length-prefixed framing rawMessageConn uses, and writes back
+// an Echo response carrying the same message.
+func serveOneEcho(t *testing.T, ln net.Listener) {
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Logf("serveOneEcho: Accept: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	var req types.Request
+	if err := types.ReadMessage(r, &req); err != nil {
+		t.Logf("serveOneEcho: ReadMessage: %v", err)
+		return
+	}
+	echo, ok := req.Value.(*types.Request_Echo)
+	if !ok {
+		t.Logf("serveOneEcho: unexpected request %T", req.Value)
+		return
+	}
+
+	res := &types.Response{Value: &types.Response_Echo{
+		Echo: &types.ResponseEcho{Message: echo.Echo.Message},
+	}}
+	if err := types.WriteMessage(res, w); err != nil {
+		t.Logf("serveOneEcho: WriteMessage: %v", err)
+		return
+	}
+	if err := w.Flush(); err != nil {
+		t.Logf("serveOneEcho: Flush: %v", err)
+	}
+}
+
+// TestRawTransportRoundTrip dials a real TCP listener and exchanges one Echo
+// request/response using rawTransport's actual wire framing, as opposed to
+// the in-memory fakeConn the rest of this package's tests rely on.
+func TestRawTransportRoundTrip(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer ln.Close()
+
+	go serveOneEcho(t, ln)
+
+	mc, err := NewRawTransport().Dial("tcp://" + ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer mc.Close()
+
+	if err := mc.SendRequest(types.ToRequestEcho("round trip")); err != nil {
+		t.Fatalf("SendRequest: %v", err)
+	}
+	if err := mc.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	var res types.Response
+	if err := mc.RecvResponse(&res); err != nil {
+		t.Fatalf("RecvResponse: %v", err)
+	}
+	echo, ok := res.Value.(*types.Response_Echo)
+	if !ok || echo.Echo.Message != "round trip" {
+		t.Fatalf("unexpected response: %+v", res)
+	}
+}
+
+// TestUnixTransportRoundTrip is TestRawTransportRoundTrip's counterpart for
+// unixTransport, over a real Unix domain socket.
+func TestUnixTransportRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "abci-unix-transport-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	sockPath := filepath.Join(dir, "abci.sock")
+
+	ln, err := net.Listen("unix", sockPath)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer ln.Close()
+
+	go serveOneEcho(t, ln)
+
+	mc, err := NewUnixTransport().Dial(sockPath)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer mc.Close()
+
+	if err := mc.SendRequest(types.ToRequestEcho("round trip")); err != nil {
+		t.Fatalf("SendRequest: %v", err)
+	}
+	if err := mc.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	var res types.Response
+	if err := mc.RecvResponse(&res); err != nil {
+		t.Fatalf("RecvResponse: %v", err)
+	}
+	echo, ok := res.Value.(*types.Response_Echo)
+	if !ok || echo.Echo.Message != "round trip" {
+		t.Fatalf("unexpected response: %+v", res)
+	}
+}
+
+// TestNewTLSTransportBadKeyPair checks that a missing/invalid cert or key
+// file fails at construction time instead of surfacing later as an opaque
+// dial error.
+func TestNewTLSTransportBadKeyPair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "abci-tls-transport-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := TLSConfig{
+		CertFile: filepath.Join(dir, "missing-cert.pem"),
+		KeyFile:  filepath.Join(dir, "missing-key.pem"),
+	}
+	if _, err := NewTLSTransport(cfg); err == nil {
+		t.Fatalf("expected an error for a missing cert/key pair")
+	}
+}